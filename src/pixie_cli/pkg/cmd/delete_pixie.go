@@ -19,7 +19,9 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
@@ -35,6 +37,8 @@ import (
 func init() {
 	DeleteCmd.Flags().BoolP("clobber", "d", true, "Whether to delete all dependencies in the cluster")
 	DeleteCmd.Flags().StringP("namespace", "n", "", "The namespace where Pixie is located")
+	DeleteCmd.Flags().Bool("wait", true, "Whether to wait for the deleted objects to be removed from the cluster")
+	DeleteCmd.Flags().Duration("delete_timeout", 2*time.Minute, "How long to wait for the deleted objects to be removed. Only used with --wait")
 }
 
 // DeleteCmd is the "delete" command.
@@ -44,18 +48,24 @@ var DeleteCmd = &cobra.Command{
 	PreRun: func(cmd *cobra.Command, args []string) {
 		viper.BindPFlag("clobber", cmd.Flags().Lookup("clobber"))
 		viper.BindPFlag("namespace", cmd.Flags().Lookup("namespace"))
+		viper.BindPFlag("wait", cmd.Flags().Lookup("wait"))
+		viper.BindPFlag("delete_timeout", cmd.Flags().Lookup("delete_timeout"))
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		clobberAll, _ := cmd.Flags().GetBool("clobber")
 		ns, _ := cmd.Flags().GetString("namespace")
+		wait, _ := cmd.Flags().GetBool("wait")
+		timeout, _ := cmd.Flags().GetDuration("delete_timeout")
 		if ns == "" {
 			ns = vizier.MustFindVizierNamespace()
+		} else if err := validateNamespace(ns); err != nil {
+			utils.WithError(err).Fatal("Invalid --namespace")
 		}
-		deletePixie(ns, clobberAll)
+		deletePixie(ns, clobberAll, wait, timeout)
 	},
 }
 
-func deletePixie(ns string, clobberAll bool) {
+func deletePixie(ns string, clobberAll bool, wait bool, timeout time.Duration) {
 	kubeConfig := k8s.GetConfig()
 	kubeAPIConfig := k8s.GetClientAPIConfig()
 	clientset := k8s.GetClientset(kubeConfig)
@@ -66,13 +76,15 @@ func deletePixie(ns string, clobberAll bool) {
 		Namespace:  ns,
 		Clientset:  clientset,
 		RestConfig: kubeConfig,
-		Timeout:    2 * time.Minute,
+		Timeout:    timeout,
+		SkipWait:   !wait,
 	}
 	opOd := k8s.ObjectDeleter{
 		Namespace:  opNs,
 		Clientset:  clientset,
 		RestConfig: kubeConfig,
-		Timeout:    2 * time.Minute,
+		Timeout:    timeout,
+		SkipWait:   !wait,
 	}
 
 	tasks := make([]utils.Task, 0)
@@ -99,6 +111,10 @@ func deletePixie(ns string, clobberAll bool) {
 			_, err := od.DeleteByLabel("app=pl-monitoring")
 			return err
 		}))
+		tasks = append(tasks, newTaskWrapper("Deleting extra manifests", func() error {
+			_, err := od.DeleteByLabel(fmt.Sprintf("%s=true", extraManifestLabel))
+			return err
+		}))
 	} else {
 		tasks = append(tasks, newTaskWrapper("Deleting Vizier pods/services", func() error {
 			_, err := od.DeleteByLabel("component=vizier")
@@ -109,6 +125,29 @@ func deletePixie(ns string, clobberAll bool) {
 	delJr := utils.NewSerialTaskRunner(tasks)
 	err := delJr.RunAndMonitor()
 	if err != nil {
+		var timeoutErr *k8s.DeleteTimeoutError
+		if errors.As(err, &timeoutErr) {
+			printStuckObjects(timeoutErr)
+		}
 		utils.WithError(err).Fatal("Error deleting Pixie")
 	}
 }
+
+// printStuckObjects reports the objects which weren't removed before the delete timed out, and why.
+func printStuckObjects(e *k8s.DeleteTimeoutError) {
+	utils.Errorf("The following objects were not removed within --delete_timeout=%s:", e.Timeout)
+	for _, o := range e.Objects {
+		name := fmt.Sprintf("%s/%s", o.Resource, o.Name)
+		if o.Namespace != "" {
+			name = fmt.Sprintf("%s (namespace %s)", name, o.Namespace)
+		}
+		if len(o.Finalizers) > 0 {
+			name = fmt.Sprintf("%s, finalizers: %s", name, strings.Join(o.Finalizers, ", "))
+		}
+		if o.Reason != "" {
+			name = fmt.Sprintf("%s, reason: %s", name, o.Reason)
+		}
+		utils.Errorf("  %s", name)
+	}
+	utils.Error("Objects are often stuck on finalizers whose controllers are no longer running.")
+}
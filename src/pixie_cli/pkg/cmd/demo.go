@@ -68,6 +68,8 @@ var DemoCmd = &cobra.Command{
 	Use:   "demo",
 	Short: "Manage demo apps",
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// This overrides the root pre run, so the log file must be set up here as well.
+		setupLogFile()
 		// This pre run might be run from a subcommand. To bind the correct flag, we should check
 		// the persistent flags on both the current command and the parent.
 		if cmd.PersistentFlags().Lookup("artifacts") != nil {
@@ -24,6 +24,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
@@ -34,9 +37,12 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
+	"gopkg.in/yaml.v2"
 	v1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
@@ -61,6 +67,8 @@ const (
 	DefaultCloudAddr = "withpixie.ai:443"
 	// DeploySuccess is the successful deploy const.
 	DeploySuccess = "successfulDeploy"
+	// extraManifestLabel is set on every object from --extra_manifests_dir, so that px delete can clean them up.
+	extraManifestLabel = "px.dev/extra-manifest"
 )
 
 // BlockListedLabels are labels that we won't allow users to specify, since these are labels that we
@@ -72,16 +80,25 @@ var BlockListedLabels = []string{
 	"app",
 }
 
+// registryRegex matches an image registry host with an optional port and repository path,
+// for example: "registry.example.com:5000/pixie". Path components follow the separator rules
+// of the distribution reference grammar.
+var registryRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9.-]*[a-zA-Z0-9])?(:[0-9]+)?(/[a-z0-9]+((?:[._]|__|[-]*)[a-z0-9]+)*)*$`)
+
 func init() {
 	DeployCmd.Flags().StringP("extract_yaml", "e", "", "Directory to extract the Pixie yamls to")
-	DeployCmd.Flags().StringP("vizier_version", "v", "", "Pixie version to deploy")
+	DeployCmd.Flags().StringP("vizier_version", "v", "", "Pixie version to deploy. Use @<path> to read the version from a file")
 	DeployCmd.Flags().BoolP("check", "c", true, "Check whether the cluster can run Pixie")
 	DeployCmd.Flags().BoolP("check_only", "", false, "Only run check and exit.")
-	DeployCmd.Flags().StringP("namespace", "n", "pl", "The namespace to deploy Vizier to")
+	DeployCmd.Flags().Bool("fail_on_warn", false, "Fail on any cluster check warning, including failed recommended checks and skipped kernel checks, rather than continuing. Requires the cluster checks to run.")
+	DeployCmd.Flags().Bool("skip_kernel_check", false, "Skip the node kernel version check, for platforms which report unreliable kernel versions.")
+	DeployCmd.Flags().String("max_k8s_version", "", "If set, fail the cluster checks when the K8s server version is newer than this version. Accepts major.minor (e.g. 1.25, which allows all 1.25.x releases) or major.minor.patch.")
+	DeployCmd.Flags().StringP("namespace", "n", "pl", "The namespace to deploy Vizier to. Must be a valid DNS-1123 label, defaults to 'pl'")
 	DeployCmd.Flags().StringP("deploy_key", "k", "", "The deploy key to use to deploy Pixie")
 	DeployCmd.Flags().BoolP("use_etcd_operator", "o", false, "Whether to use the operator for etcd instead of the statefulset")
 	DeployCmd.Flags().StringP("labels", "l", "", "Custom labels to apply to Pixie resources")
 	DeployCmd.Flags().StringP("annotations", "t", "", "Custom annotations to apply to Pixie resources")
+	DeployCmd.Flags().String("metadata_file", "", "YAML or JSON file with 'labels' and 'annotations' maps to apply to Pixie resources. Values from --labels and --annotations take precedence.")
 	DeployCmd.Flags().StringP("cluster_name", "u", "", "The name for your cluster. Otherwise, the name will be taken from the current kubeconfig.")
 	DeployCmd.Flags().StringP("pem_memory_limit", "p", "", "The memory limit to specify for the PEMs, otherwise a default is used.")
 	DeployCmd.Flags().StringP("pem_memory_request", "r", "", "The memory request to specify for the PEMs, otherwise a default is used.")
@@ -89,6 +106,13 @@ func init() {
 	DeployCmd.Flags().String("pem_flags", "", "Flags to be set on the PEM.")
 	DeployCmd.Flags().String("registry", "", "The custom image registry to use rather than Pixie's default (gcr.io).")
 	DeployCmd.Flags().BoolP("disable_auto_update", "d", false, "Disable the auto-update feature for the vizier client.")
+	DeployCmd.Flags().Bool("force_recreate_namespace", false, "Delete and recreate the Vizier namespace, and everything in it, before deploying.")
+	DeployCmd.Flags().Bool("allow_downgrade", false, "Allow deploying a Vizier version older than the one currently installed.")
+	DeployCmd.Flags().Bool("no_create_namespace", false, "Fail if the Vizier namespace does not already exist, rather than creating it.")
+	DeployCmd.Flags().Bool("watch_events", false, "Print warning events from the Vizier namespace while waiting for Pixie to become healthy.")
+	DeployCmd.Flags().String("extra_manifests_dir", "", "Directory of additional YAML manifests to apply, in filename order, after Vizier is deployed. Objects without a namespace are placed in the Vizier namespace.")
+	DeployCmd.Flags().String("post_deploy_script", "", "Executable to run after a successful deploy. The namespace, version and cluster ID are passed in the PX_NAMESPACE, PX_VIZIER_VERSION and PX_CLUSTER_ID env vars.")
+	DeployCmd.Flags().Bool("ignore_hook_errors", false, "Whether to continue when the post_deploy_script fails, rather than failing the deploy.")
 
 	// Flags for deploying OLM.
 	DeployCmd.Flags().String("operator_version", "", "Operator version to deploy")
@@ -111,11 +135,15 @@ var DeployCmd = &cobra.Command{
 		viper.BindPFlag("vizier_version", cmd.Flags().Lookup("vizier_version"))
 		viper.BindPFlag("check", cmd.Flags().Lookup("check"))
 		viper.BindPFlag("check_only", cmd.Flags().Lookup("check_only"))
+		viper.BindPFlag("fail_on_warn", cmd.Flags().Lookup("fail_on_warn"))
+		viper.BindPFlag("skip_kernel_check", cmd.Flags().Lookup("skip_kernel_check"))
+		viper.BindPFlag("max_k8s_version", cmd.Flags().Lookup("max_k8s_version"))
 		viper.BindPFlag("namespace", cmd.Flags().Lookup("namespace"))
 		viper.BindPFlag("deploy_key", cmd.Flags().Lookup("deploy_key"))
 		viper.BindPFlag("use_etcd_operator", cmd.Flags().Lookup("use_etcd_operator"))
 		viper.BindPFlag("labels", cmd.Flags().Lookup("labels"))
 		viper.BindPFlag("annotations", cmd.Flags().Lookup("annotations"))
+		viper.BindPFlag("metadata_file", cmd.Flags().Lookup("metadata_file"))
 		viper.BindPFlag("cluster_name", cmd.Flags().Lookup("cluster_name"))
 		viper.BindPFlag("pem_memory_limit", cmd.Flags().Lookup("pem_memory_limit"))
 		viper.BindPFlag("pem_memory_request", cmd.Flags().Lookup("pem_memory_request"))
@@ -129,6 +157,13 @@ var DeployCmd = &cobra.Command{
 		viper.BindPFlag("datastream_buffer_size", cmd.Flags().Lookup("datastream_buffer_size"))
 		viper.BindPFlag("datastream_buffer_spike_size", cmd.Flags().Lookup("datastream_buffer_spike_size"))
 		viper.BindPFlag("disable_auto_update", cmd.Flags().Lookup("disable_auto_update"))
+		viper.BindPFlag("force_recreate_namespace", cmd.Flags().Lookup("force_recreate_namespace"))
+		viper.BindPFlag("allow_downgrade", cmd.Flags().Lookup("allow_downgrade"))
+		viper.BindPFlag("no_create_namespace", cmd.Flags().Lookup("no_create_namespace"))
+		viper.BindPFlag("watch_events", cmd.Flags().Lookup("watch_events"))
+		viper.BindPFlag("extra_manifests_dir", cmd.Flags().Lookup("extra_manifests_dir"))
+		viper.BindPFlag("post_deploy_script", cmd.Flags().Lookup("post_deploy_script"))
+		viper.BindPFlag("ignore_hook_errors", cmd.Flags().Lookup("ignore_hook_errors"))
 	},
 	PostRun: func(cmd *cobra.Command, args []string) {
 		if cmd.Annotations["status"] != DeploySuccess {
@@ -136,7 +171,7 @@ var DeployCmd = &cobra.Command{
 		}
 
 		p := func(s string, a ...interface{}) {
-			fmt.Fprintf(os.Stderr, s, a...)
+			fmt.Fprintf(utils.Output(), s, a...)
 		}
 		u := color.New(color.Underline).Sprintf
 		b := color.New(color.Bold).Sprintf
@@ -148,7 +183,7 @@ var DeployCmd = &cobra.Command{
 			docsAddr = "px.dev"
 		}
 
-		fmt.Fprint(os.Stderr, "\n")
+		fmt.Fprint(utils.Output(), "\n")
 		p(color.CyanString("==> ") + b("Next Steps:\n"))
 		p("\nRun some scripts using the %s cli. For example: \n", g("px"))
 		p("- %s : to show pre-installed scripts.\n", g("px script list"))
@@ -180,6 +215,51 @@ func (t *taskWrapper) Run() error {
 	return t.run()
 }
 
+// timedTask is a task which records how long it took to run.
+type timedTask struct {
+	utils.Task
+	elapsed time.Duration
+}
+
+func (t *timedTask) Run() error {
+	start := time.Now()
+	err := t.Task.Run()
+	t.elapsed = time.Since(start)
+	return err
+}
+
+// phaseTimer tracks the run time of each of the deploy tasks, so that slow phases can be identified.
+type phaseTimer struct {
+	tasks []*timedTask
+}
+
+// track wraps the given tasks so that their run times are recorded.
+func (p *phaseTimer) track(tasks []utils.Task) []utils.Task {
+	tracked := make([]utils.Task, len(tasks))
+	for i, t := range tasks {
+		tt := &timedTask{Task: t}
+		p.tasks = append(p.tasks, tt)
+		tracked[i] = tt
+	}
+	return tracked
+}
+
+// printSummary prints the run time of each task that was run.
+func (p *phaseTimer) printSummary() {
+	w := components.CreateStreamWriter("table", utils.Output())
+	defer w.Finish()
+	w.SetHeader("deploy_phases", []string{"Phase", "Duration"})
+	total := time.Duration(0)
+	for _, t := range p.tasks {
+		if t.elapsed == 0 {
+			continue
+		}
+		total += t.elapsed
+		_ = w.Write([]interface{}{t.Name(), t.elapsed.Round(time.Millisecond).String()})
+	}
+	_ = w.Write([]interface{}{"Total", total.Round(time.Millisecond).String()})
+}
+
 func newArtifactTrackerClient(conn *grpc.ClientConn) cloudpb.ArtifactTrackerClient {
 	return cloudpb.NewArtifactTrackerClient(conn)
 }
@@ -226,21 +306,162 @@ func getLatestOperatorVersion(conn *grpc.ClientConn) (string, error) {
 	return resp.Artifact[0].VersionStr, nil
 }
 
+// readVersionString returns the given version string. If the version is of the form @<path>,
+// the version is read from the file at that path instead.
+func readVersionString(v string) (string, error) {
+	if !strings.HasPrefix(v, "@") {
+		return v, nil
+	}
+	path := strings.TrimPrefix(v, "@")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	v = strings.TrimSpace(string(b))
+	if v == "" {
+		return "", fmt.Errorf("version file %s is empty", path)
+	}
+	return v, nil
+}
+
+// checkNotDowngrade returns an error if the Vizier installed in the namespace is newer than the given version.
+func checkNotDowngrade(vzClient versioned.Interface, namespace string, version string) error {
+	vz, err := vzClient.PxV1alpha1().Viziers(namespace).Get(context.Background(), "pixie", metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if isDowngrade(vz.Status.Version, version) {
+		return fmt.Errorf("requested version %s is older than the installed version %s", version, vz.Status.Version)
+	}
+	return nil
+}
+
+// isDowngrade returns whether the requested version is older than the installed version, taking pre-release
+// tags into account. Versions that can't be parsed are not considered a downgrade.
+func isDowngrade(installed string, requested string) bool {
+	c, err := utils.CompareVersions(requested, installed)
+	return err == nil && c < 0
+}
+
+// missingYAMLs returns the names of the manifests that deploy requires but are absent or empty in the given map.
+func missingYAMLs(yamlMap map[string]string, deployOLM bool) []string {
+	required := []string{"px_olm", "catalog", "subscription", "vizier_crd", "vizier"}
+	if deployOLM {
+		required = append([]string{"olm_crd", "olm"}, required...)
+	}
+	missing := make([]string, 0)
+	for _, name := range required {
+		if strings.TrimSpace(yamlMap[name]) == "" {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// validateNamespace checks that the given namespace is a valid DNS-1123 label.
+func validateNamespace(ns string) error {
+	if ns == "" {
+		return errors.New("namespace must not be empty")
+	}
+	if errs := validation.IsDNS1123Label(ns); len(errs) != 0 {
+		return fmt.Errorf("namespace %q is invalid: %s", ns, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// deployMetadata is the format of the file passed in through --metadata_file.
+type deployMetadata struct {
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// readMetadataFile reads the labels and annotations from the given YAML or JSON file.
+func readMetadataFile(path string) (*deployMetadata, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	md := &deployMetadata{}
+	if err := yaml.UnmarshalStrict(b, md); err != nil {
+		return nil, err
+	}
+	return md, nil
+}
+
+// mergeMetadata merges the metadata from --metadata_file with the metadata from flags. Values from
+// flags take precedence.
+func mergeMetadata(fromFile, fromFlags map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for k, v := range fromFile {
+		merged[k] = v
+	}
+	for k, v := range fromFlags {
+		merged[k] = v
+	}
+	return merged
+}
+
+// validateMetadata checks that the given labels and annotations are valid K8s metadata.
+func validateMetadata(labels, annotations map[string]string) error {
+	for k, v := range labels {
+		if errs := validation.IsQualifiedName(k); len(errs) != 0 {
+			return fmt.Errorf("invalid label key %q: %s", k, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) != 0 {
+			return fmt.Errorf("invalid value for label %q: %s", k, strings.Join(errs, "; "))
+		}
+	}
+	for k := range annotations {
+		if errs := validation.IsQualifiedName(k); len(errs) != 0 {
+			return fmt.Errorf("invalid annotation key %q: %s", k, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
 func runDeployCmd(cmd *cobra.Command, args []string) {
 	check, _ := cmd.Flags().GetBool("check")
 	checkOnly, _ := cmd.Flags().GetBool("check_only")
+	failOnWarn, _ := cmd.Flags().GetBool("fail_on_warn")
+	skipKernelCheck, _ := cmd.Flags().GetBool("skip_kernel_check")
+	maxK8sVersion, _ := cmd.Flags().GetString("max_k8s_version")
 	extractPath, _ := cmd.Flags().GetString("extract_yaml")
+	if maxK8sVersion != "" {
+		if _, err := utils.ParseMaxVersion(maxK8sVersion); err != nil {
+			utils.WithError(err).Fatal("--max_k8s_version must be of the form major.minor or major.minor.patch")
+		}
+		if !(check || checkOnly) || extractPath != "" {
+			utils.Fatal("--max_k8s_version requires the cluster checks to run. Remove --check=false or --extract_yaml.")
+		}
+	}
+	if failOnWarn && (!(check || checkOnly) || extractPath != "") {
+		utils.Fatal("--fail_on_warn requires the cluster checks to run. Remove --check=false or --extract_yaml.")
+	}
 
 	// OLM flags.
 	deployOLM, _ := cmd.Flags().GetBool("deploy_olm")
 	olmNamespace, _ := cmd.Flags().GetString("olm_namespace")
 	olmOperatorNamespace, _ := cmd.Flags().GetString("olm_operator_namespace")
+	namespace, _ := cmd.Flags().GetString("namespace")
+	for _, ns := range []struct{ flag, value string }{
+		{"namespace", namespace},
+		{"olm_namespace", olmNamespace},
+		{"olm_operator_namespace", olmOperatorNamespace},
+	} {
+		if err := validateNamespace(ns.value); err != nil {
+			utils.WithError(err).Fatalf("Invalid --%s", ns.flag)
+		}
+	}
 
 	deployKey, _ := cmd.Flags().GetString("deploy_key")
 	useEtcdOperator, _ := cmd.Flags().GetBool("use_etcd_operator")
 	disableAutoUpdate, _ := cmd.Flags().GetBool("disable_auto_update")
 	customLabels, _ := cmd.Flags().GetString("labels")
 	customAnnotations, _ := cmd.Flags().GetString("annotations")
+	metadataFile, _ := cmd.Flags().GetString("metadata_file")
 	pemMemoryLimit, _ := cmd.Flags().GetString("pem_memory_limit")
 	pemMemoryRequest, _ := cmd.Flags().GetString("pem_memory_request")
 	pemFlags, _ := cmd.Flags().GetString("pem_flags")
@@ -249,15 +470,34 @@ func runDeployCmd(cmd *cobra.Command, args []string) {
 	datastreamBufferSize, _ := cmd.Flags().GetUint32("datastream_buffer_size")
 	datastreamBufferSpikeSize, _ := cmd.Flags().GetUint32("datastream_buffer_spike_size")
 	registry, _ := cmd.Flags().GetString("registry")
-
-	labelMap := make(map[string]string)
+	recreateNamespace, _ := cmd.Flags().GetBool("force_recreate_namespace")
+	noCreateNamespace, _ := cmd.Flags().GetBool("no_create_namespace")
+	allowDowngrade, _ := cmd.Flags().GetBool("allow_downgrade")
+	if recreateNamespace && noCreateNamespace {
+		utils.Fatal("--force_recreate_namespace and --no_create_namespace cannot both be set")
+	}
+	extraManifestsDir, _ := cmd.Flags().GetString("extra_manifests_dir")
+	watchEvents, _ := cmd.Flags().GetBool("watch_events")
+	postDeployScript, _ := cmd.Flags().GetString("post_deploy_script")
+	ignoreHookErrors, _ := cmd.Flags().GetBool("ignore_hook_errors")
+
+	fileMetadata := &deployMetadata{}
+	if metadataFile != "" {
+		md, err := readMetadataFile(metadataFile)
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to read --metadata_file")
+		}
+		fileMetadata = md
+	}
+	var flagLabels map[string]string
 	if customLabels != "" {
 		lm, err := k8s.KeyValueStringToMap(customLabels)
 		if err != nil {
 			utils.WithError(err).Fatal("--labels must be specified through the following format: label1=value1,label2=value2")
 		}
-		labelMap = lm
+		flagLabels = lm
 	}
+	labelMap := mergeMetadata(fileMetadata.Labels, flagLabels)
 	// Check that none of the labels override ours.
 	for _, l := range BlockListedLabels {
 		if _, ok := labelMap[l]; ok {
@@ -265,13 +505,17 @@ func runDeployCmd(cmd *cobra.Command, args []string) {
 			utils.Fatalf("Custom labels must not be one of: %s.", joinedLabels)
 		}
 	}
-	annotationMap := make(map[string]string)
+	var flagAnnotations map[string]string
 	if customAnnotations != "" {
 		am, err := k8s.KeyValueStringToMap(customAnnotations)
 		if err != nil {
 			utils.WithError(err).Fatal("--annotations must be specified through the following format: annotation1=value1,annotation2=value2")
 		}
-		annotationMap = am
+		flagAnnotations = am
+	}
+	annotationMap := mergeMetadata(fileMetadata.Annotations, flagAnnotations)
+	if err := validateMetadata(labelMap, annotationMap); err != nil {
+		utils.WithError(err).Fatal("Invalid custom labels or annotations")
 	}
 	patchesMap := make(map[string]string)
 	if len(patches) != 0 {
@@ -300,6 +544,27 @@ func runDeployCmd(cmd *cobra.Command, args []string) {
 		dataCollectorParams["datastreamBufferSpikeSize"] = datastreamBufferSpikeSize
 	}
 
+	// Image paths are built as <registry>/<image>, so drop any trailing slash to avoid empty path components.
+	registry = strings.TrimSuffix(registry, "/")
+	if registry != "" && !registryRegex.MatchString(registry) {
+		utils.Fatalf("--registry '%s' is not a valid image registry, for example: registry.example.com:5000/pixie", registry)
+	}
+
+	if postDeployScript != "" {
+		if _, err := exec.LookPath(postDeployScript); err != nil {
+			utils.WithError(err).Fatal("--post_deploy_script must be an executable")
+		}
+	}
+
+	var extraManifests []*yamlsutils.YAMLFile
+	if extraManifestsDir != "" {
+		em, err := readExtraManifests(extraManifestsDir, namespace)
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to read --extra_manifests_dir")
+		}
+		extraManifests = em
+	}
+
 	castedDataAccess := vztypes.DataAccessLevel(dataAccess)
 	if castedDataAccess != vztypes.DataAccessFull && castedDataAccess != vztypes.DataAccessRestricted {
 		utils.Fatal("--data_access must be a valid data access level")
@@ -315,7 +580,13 @@ func runDeployCmd(cmd *cobra.Command, args []string) {
 			Event:  "Cluster Check Run",
 		})
 
-		err := utils.RunDefaultClusterChecks()
+		err := utils.RunDefaultClusterChecks(&utils.ClusterCheckOptions{
+			SkipKernelCheck: skipKernelCheck,
+			MaxK8sVersion:   maxK8sVersion,
+			FailOnWarn:      failOnWarn,
+			// The namespace won't be created, so the user doesn't need permission to create it.
+			SkipCreateNamespaceCheck: noCreateNamespace,
+		})
 		if err != nil {
 			_ = pxanalytics.Client().Enqueue(&analytics.Track{
 				UserId: pxconfig.Cfg().UniqueClientID,
@@ -326,12 +597,19 @@ func runDeployCmd(cmd *cobra.Command, args []string) {
 			utils.WithError(err).Fatal("Check pre-check has failed. To bypass pass in --check=false.")
 		}
 
-		if checkOnly {
+		if checkOnly && !failOnWarn {
 			log.Info("All Required Checks Passed!")
 			os.Exit(0)
 		}
 
 		err = utils.RunExtraClusterChecks()
+		if err != nil && failOnWarn {
+			utils.WithError(err).Fatal("Recommended cluster checks failed and --fail_on_warn is set.")
+		}
+		if checkOnly {
+			log.Info("All Checks Passed!")
+			os.Exit(0)
+		}
 		if err != nil {
 			clusterOk := components.YNPrompt("Some cluster checks failed. Pixie may not work properly on your cluster. Continue with deploy?", true)
 			if !clusterOk {
@@ -341,7 +619,6 @@ func runDeployCmd(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	namespace, _ := cmd.Flags().GetString("namespace")
 	devCloudNS := viper.GetString("dev_cloud_namespace")
 	cloudAddr := viper.GetString("cloud_addr")
 
@@ -352,7 +629,10 @@ func runDeployCmd(cmd *cobra.Command, args []string) {
 		log.WithError(err).Fatalln("Failed to get grpc connection to cloud")
 	}
 
-	versionString := viper.GetString("vizier_version")
+	versionString, err := readVersionString(viper.GetString("vizier_version"))
+	if err != nil {
+		utils.WithError(err).Fatal("Failed to read --vizier_version")
+	}
 	if len(versionString) == 0 {
 		// Fetch latest version.
 		versionString, err = getLatestVizierVersion(cloudConn)
@@ -376,6 +656,31 @@ func runDeployCmd(cmd *cobra.Command, args []string) {
 		olmBundleChannel = "dev"
 	}
 
+	kubeConfig := k8s.GetConfig()
+	kubeAPIConfig := k8s.GetClientAPIConfig()
+	clientset := k8s.GetClientset(kubeConfig)
+	vzClient, err := versioned.NewForConfig(kubeConfig)
+	if err != nil {
+		log.WithError(err).Fatal("Could not start vizier client")
+	}
+
+	// Nothing is applied when extracting the YAMLs, so there is nothing to protect and no need for a live cluster.
+	// This runs before the deploy key is generated, so that a refusal doesn't leave an orphaned key behind.
+	if !allowDowngrade && extractPath == "" {
+		if err := checkNotDowngrade(vzClient, namespace, versionString); err != nil {
+			utils.WithError(err).Fatal("Refusing to deploy. Pass --allow_downgrade to deploy anyway")
+		}
+	}
+	if noCreateNamespace && extractPath == "" {
+		_, err := clientset.CoreV1().Namespaces().Get(context.Background(), namespace, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			utils.Fatalf("Namespace '%s' does not exist and --no_create_namespace is set. Create it before deploying.", namespace)
+		}
+		if err != nil {
+			utils.WithError(err).Fatalf("Failed to check namespace '%s'", namespace)
+		}
+	}
+
 	// Get deploy key, if not already specified.
 	var deployKeyID string
 	if deployKey == "" {
@@ -392,14 +697,6 @@ func runDeployCmd(cmd *cobra.Command, args []string) {
 		}()
 	}
 
-	kubeConfig := k8s.GetConfig()
-	kubeAPIConfig := k8s.GetClientAPIConfig()
-	clientset := k8s.GetClientset(kubeConfig)
-	vzClient, err := versioned.NewForConfig(kubeConfig)
-	if err != nil {
-		log.WithError(err).Fatal("Could not start vizier client")
-	}
-
 	utils.Infof("Generating YAMLs for Pixie")
 
 	templatedYAMLs, err := artifacts.FetchOperatorTemplates(cloudConn, operatorVersion)
@@ -455,6 +752,7 @@ func runDeployCmd(cmd *cobra.Command, args []string) {
 
 	// If extract_path is specified, write out yamls to file.
 	if extractPath != "" {
+		yamls = append(yamls, extraManifests...)
 		if err := yamlsutils.ExtractYAMLs(yamls, extractPath, "pixie_yamls", yamlsutils.MultiFileExtractYAMLFormat); err != nil {
 			log.WithError(err).Fatal("failed to extract deployment YAMLs")
 		}
@@ -466,6 +764,9 @@ func runDeployCmd(cmd *cobra.Command, args []string) {
 	for _, y := range yamls {
 		yamlMap[y.Name] = y.YAML
 	}
+	if missing := missingYAMLs(yamlMap, deployOLM); len(missing) != 0 {
+		utils.Fatalf("The fetched deployment YAMLs are missing required manifests: %s", strings.Join(missing, ", "))
+	}
 
 	_ = pxanalytics.Client().Enqueue(&analytics.Track{
 		UserId: pxconfig.Cfg().UniqueClientID,
@@ -489,6 +790,17 @@ func runDeployCmd(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	if recreateNamespace {
+		utils.WithColor(color.New(color.FgRed)).Infof("This action will delete the entire '%s' namespace before deploying.", namespace)
+		// This is destructive, so only --y skips the prompt. Anything but an explicit yes, including a
+		// non-interactive stdin, aborts.
+		proceed := viper.GetBool("y") || components.YNPrompt("Delete and recreate the namespace?", false)
+		if !proceed {
+			utils.Error("Deploy cancelled. Aborting...")
+			return
+		}
+	}
+
 	// Get the number of nodes.
 	numNodes, err := getNumNodes(clientset)
 	if err != nil {
@@ -501,15 +813,36 @@ func runDeployCmd(cmd *cobra.Command, args []string) {
 
 	utils.Infof("Found %v nodes", numNodes)
 
-	clusterID := deploy(cloudConn, clientset, vzClient, kubeConfig, yamlMap, deployOLM, olmNamespace, olmOperatorNamespace, namespace)
+	// Watch events for the whole deploy, so that scheduling and image pull failures that stall the
+	// cloud connector are visible too.
+	stopEvents := func() {}
+	if watchEvents {
+		stopEvents = watchWarningEvents(clientset, namespace)
+	}
+
+	timer := &phaseTimer{}
+	clusterID := deploy(cloudConn, clientset, vzClient, kubeConfig, yamlMap, deployOLM, olmNamespace, olmOperatorNamespace, namespace, recreateNamespace, !noCreateNamespace, extraManifests, timer)
 
-	waitForHealthCheck(cloudAddr, clusterID, clientset, namespace, numNodes)
+	waitForHealthCheck(cloudAddr, clusterID, clientset, namespace, numNodes, timer)
+	stopEvents()
+
+	if postDeployScript != "" {
+		utils.Infof("Running post-deploy script: %s", postDeployScript)
+		err := runPostDeployScript(postDeployScript, namespace, versionString, clusterID)
+		if err != nil && !ignoreHookErrors {
+			utils.WithError(err).Fatal("Post-deploy script failed. To ignore script failures, pass in --ignore_hook_errors.")
+		}
+		if err != nil {
+			utils.WithError(err).Error("Post-deploy script failed, continuing")
+		}
+	}
+	timer.printSummary()
 
 	cmd.Annotations = make(map[string]string)
 	cmd.Annotations["status"] = DeploySuccess
 }
 
-func deploy(cloudConn *grpc.ClientConn, clientset *kubernetes.Clientset, vzClient *versioned.Clientset, kubeConfig *rest.Config, yamlMap map[string]string, deployOLM bool, olmNs, olmOpNs, namespace string) uuid.UUID {
+func deploy(cloudConn *grpc.ClientConn, clientset *kubernetes.Clientset, vzClient *versioned.Clientset, kubeConfig *rest.Config, yamlMap map[string]string, deployOLM bool, olmNs, olmOpNs, namespace string, recreateNamespace, createNamespace bool, extraManifests []*yamlsutils.YAMLFile, timer *phaseTimer) uuid.UUID {
 	olmCRDJob := newTaskWrapper("Installing OLM CRDs", func() error {
 		return retryDeploy(clientset, kubeConfig, yamlMap["olm_crd"])
 	})
@@ -529,6 +862,22 @@ func deploy(cloudConn *grpc.ClientConn, clientset *kubernetes.Clientset, vzClien
 	})
 
 	namespaceJob := newTaskWrapper("Creating namespace", func() error {
+		if !createNamespace {
+			return nil
+		}
+		if recreateNamespace {
+			od := k8s.ObjectDeleter{
+				Namespace:  namespace,
+				Clientset:  clientset,
+				RestConfig: kubeConfig,
+				Timeout:    2 * time.Minute,
+			}
+			// Waits for the namespace to finish terminating.
+			if err := od.DeleteNamespace(); err != nil && !k8serrors.IsNotFound(err) {
+				return err
+			}
+		}
+
 		// Create namespace, if needed.
 		ns := &v1.Namespace{}
 		ns.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Namespace"))
@@ -589,7 +938,25 @@ func deploy(cloudConn *grpc.ClientConn, clientset *kubernetes.Clientset, vzClien
 		}
 	}
 
-	jr := utils.NewSerialTaskRunner(deployJobs)
+	if len(extraManifests) > 0 {
+		deployJobs = append(deployJobs, newTaskWrapper("Applying extra manifests", func() error {
+			for _, m := range extraManifests {
+				err := retryApply(func() error {
+					resources, err := k8s.GetResourcesFromYAML(strings.NewReader(m.YAML))
+					if err != nil {
+						return err
+					}
+					return k8s.CreateOrReplaceResources(clientset, kubeConfig, resources, namespace)
+				})
+				if err != nil {
+					return fmt.Errorf("failed to apply %s: %w", m.Name, err)
+				}
+			}
+			return nil
+		}))
+	}
+
+	jr := utils.NewSerialTaskRunner(timer.track(deployJobs))
 	err := jr.RunAndMonitor()
 	if err != nil {
 		_ = pxanalytics.Client().Enqueue(&analytics.Track{
@@ -605,6 +972,70 @@ func deploy(cloudConn *grpc.ClientConn, clientset *kubernetes.Clientset, vzClien
 	return clusterID
 }
 
+// readExtraManifests reads the YAML files in the given directory, in filename order. Each object is labeled with
+// extraManifestLabel, so that it is cleaned up by px delete, and objects without a namespace are placed in the
+// given namespace. The namespace is ignored for cluster-scoped objects when they are applied.
+func readExtraManifests(dir string, namespace string) ([]*yamlsutils.YAMLFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := make([]*yamlsutils.YAMLFile, 0)
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		resources, err := k8s.GetResourcesFromYAML(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", e.Name(), err)
+		}
+
+		contents := ""
+		for _, r := range resources {
+			labels := r.Object.GetLabels()
+			if labels == nil {
+				labels = make(map[string]string)
+			}
+			labels[extraManifestLabel] = "true"
+			r.Object.SetLabels(labels)
+			if r.Object.GetNamespace() == "" {
+				r.Object.SetNamespace(namespace)
+			}
+
+			y, err := k8s.ConvertResourceToYAML(r.Object)
+			if err != nil {
+				return nil, err
+			}
+			contents = yamlsutils.ConcatYAMLs(contents, y)
+		}
+		name := "extra_" + strings.TrimSuffix(e.Name(), ext)
+		manifests = append(manifests, &yamlsutils.YAMLFile{Name: name, YAML: contents})
+	}
+	return manifests, nil
+}
+
+// runPostDeployScript runs the user-provided post-deploy script and prints its output.
+func runPostDeployScript(path, namespace, version string, clusterID uuid.UUID) error {
+	c := exec.Command(path)
+	c.Env = append(os.Environ(),
+		fmt.Sprintf("PX_NAMESPACE=%s", namespace),
+		fmt.Sprintf("PX_VIZIER_VERSION=%s", version),
+		fmt.Sprintf("PX_CLUSTER_ID=%s", clusterID.String()),
+	)
+	out, err := c.CombinedOutput()
+	if len(out) > 0 {
+		utils.Infof("%s", strings.TrimRight(string(out), "\n"))
+	}
+	return err
+}
+
 func runSimpleHealthCheckScript(cloudAddr string, clusterID uuid.UUID) error {
 	v, err := vizier.ConnectionToVizierByID(cloudAddr, clusterID)
 	br := mustCreateBundleReader()
@@ -679,7 +1110,7 @@ func waitForHealthCheckTaskGenerator(cloudAddr string, clusterID uuid.UUID) func
 	}
 }
 
-func waitForHealthCheck(cloudAddr string, clusterID uuid.UUID, clientset *kubernetes.Clientset, namespace string, numNodes int) {
+func waitForHealthCheck(cloudAddr string, clusterID uuid.UUID, clientset *kubernetes.Clientset, namespace string, numNodes int, timer *phaseTimer) {
 	utils.Info("Waiting for Pixie to pass healthcheck")
 
 	healthCheckJobs := []utils.Task{
@@ -689,7 +1120,7 @@ func waitForHealthCheck(cloudAddr string, clusterID uuid.UUID, clientset *kubern
 		newTaskWrapper("Wait for healthcheck", waitForHealthCheckTaskGenerator(cloudAddr, clusterID)),
 	}
 
-	hc := utils.NewSerialTaskRunner(healthCheckJobs)
+	hc := utils.NewSerialTaskRunner(timer.track(healthCheckJobs))
 	err := hc.RunAndMonitor()
 	if err != nil {
 		_ = pxanalytics.Client().Enqueue(&analytics.Track{
@@ -706,6 +1137,67 @@ func waitForHealthCheck(cloudAddr string, clusterID uuid.UUID, clientset *kubern
 	})
 }
 
+// watchWarningEvents prints the warning events in the namespace as they occur, until the returned function is called.
+// Events which happened before the watch started are not printed.
+func watchWarningEvents(clientset kubernetes.Interface, namespace string) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := metav1.ListOptions{FieldSelector: "type=Warning"}
+	// List first, so that the watch starts from the current state rather than replaying old events.
+	existing, err := clientset.CoreV1().Events(namespace).List(ctx, opts)
+	if err != nil {
+		log.WithError(err).Info("Failed to list events")
+		return cancel
+	}
+	opts.ResourceVersion = existing.ResourceVersion
+
+	go func() {
+		for ctx.Err() == nil {
+			w, err := clientset.CoreV1().Events(namespace).Watch(ctx, opts)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.WithError(err).Info("Failed to watch events")
+				}
+				return
+			}
+			resume := printWarningEvents(ctx, w, &opts.ResourceVersion)
+			w.Stop()
+			if !resume {
+				return
+			}
+		}
+	}()
+	return cancel
+}
+
+// printWarningEvents prints the events from the watch until it is closed or the context is cancelled, and records
+// the resource version of the last event seen. It returns whether the watch should be resumed.
+func printWarningEvents(ctx context.Context, w watch.Interface, resourceVersion *string) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case ev, ok := <-w.ResultChan():
+			if !ok {
+				// The server closes long running watches, so resume from the last seen event.
+				return true
+			}
+			switch ev.Type {
+			case watch.Error:
+				log.WithError(k8serrors.FromObject(ev.Object)).Info("Stopped watching events")
+				return false
+			case watch.Added, watch.Modified:
+				e, ok := ev.Object.(*v1.Event)
+				if !ok {
+					continue
+				}
+				*resourceVersion = e.ResourceVersion
+				utils.WithColor(color.New(color.FgYellow)).Infof("[%s] %s/%s: %s",
+					e.Reason, strings.ToLower(e.InvolvedObject.Kind), e.InvolvedObject.Name, e.Message)
+			}
+		}
+	}
+}
+
 func waitForCluster(ctx context.Context, conn *grpc.ClientConn, clusterID uuid.UUID) error {
 	client := cloudpb.NewVizierClusterInfoClient(conn)
 
@@ -753,24 +1245,44 @@ func initiateUpdate(ctx context.Context, conn *grpc.ClientConn, clusterID uuid.U
 }
 
 func retryDeploy(clientset *kubernetes.Clientset, config *rest.Config, yamlContents string) error {
-	tries := 12
-	var err error
-	for tries > 0 {
-		err = k8s.ApplyYAML(clientset, config, "", strings.NewReader(yamlContents), false)
-		if err == nil {
+	return retryApply(func() error {
+		err := k8s.ApplyYAML(clientset, config, "", strings.NewReader(yamlContents), false)
+		if k8serrors.IsAlreadyExists(err) {
 			return nil
 		}
+		return err
+	})
+}
 
-		if err != nil && k8serrors.IsAlreadyExists(err) {
+// retryApply runs the given apply until it succeeds, retrying transient failures.
+func retryApply(apply func() error) error {
+	const (
+		maxAttempts = 12
+		retryDelay  = 5 * time.Second
+	)
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err = apply()
+		if err == nil {
+			if attempt > 1 {
+				log.Infof("Deploy succeeded on attempt %d/%d", attempt, maxAttempts)
+			}
 			return nil
 		}
-		time.Sleep(5 * time.Second)
-		tries--
-	}
-	if tries == 0 {
-		return err
+		// A webhook rejecting the request won't change its mind on retry, unlike a webhook that timed out.
+		if attempt == maxAttempts || isWebhookDenial(err) {
+			break
+		}
+		log.WithError(err).Warnf("Deploy attempt %d/%d failed, retrying in %s", attempt, maxAttempts, retryDelay)
+		time.Sleep(retryDelay)
 	}
-	return nil
+	return err
+}
+
+// isWebhookDenial returns whether the error is from an admission webhook rejecting the request.
+func isWebhookDenial(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "admission webhook") && strings.Contains(msg, "denied the request")
 }
 
 func isPodUnschedulable(podStatus *v1.PodStatus) bool {
@@ -791,6 +1303,24 @@ func podUnschedulableMessage(podStatus *v1.PodStatus) string {
 	return ""
 }
 
+// podImagePullError returns a description of the image pull failure for the pod, if any of its containers failed to
+// pull their image.
+func podImagePullError(podStatus *v1.PodStatus) string {
+	statuses := make([]v1.ContainerStatus, 0, len(podStatus.InitContainerStatuses)+len(podStatus.ContainerStatuses))
+	statuses = append(statuses, podStatus.InitContainerStatuses...)
+	statuses = append(statuses, podStatus.ContainerStatuses...)
+	for _, cs := range statuses {
+		if cs.State.Waiting == nil {
+			continue
+		}
+		reason := cs.State.Waiting.Reason
+		if reason == "ImagePullBackOff" || reason == "ErrImagePull" {
+			return fmt.Sprintf("%s: %s %s", cs.Image, reason, cs.State.Waiting.Message)
+		}
+	}
+	return ""
+}
+
 func getNumNodes(clientset *kubernetes.Clientset) (int, error) {
 	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
 	if err != nil {
@@ -825,6 +1355,9 @@ func waitForPems(clientset *kubernetes.Clientset, namespace string, expectedPods
 
 		switch pod.Status.Phase {
 		case "Pending":
+			if pullErr := podImagePullError(&pod.Status); pullErr != "" {
+				return fmt.Errorf("failed to pull image for PEM '%s': '%s'", pod.Name, pullErr)
+			}
 			if isPodUnschedulable(&pod.Status) {
 				failedSchedulingPods[pod.Name] = podUnschedulableMessage(&pod.Status)
 			}
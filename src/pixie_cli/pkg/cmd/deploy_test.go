@@ -0,0 +1,509 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"px.dev/pixie/src/pixie_cli/pkg/utils"
+)
+
+func TestIsWebhookDenial(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name: "webhook denial",
+			err: k8serrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "vizier-pem",
+				errors.New(`admission webhook "validation.gatekeeper.sh" denied the request: privileged containers are not allowed`)),
+			expected: true,
+		},
+		{
+			name:     "webhook timeout",
+			err:      k8serrors.NewInternalError(errors.New(`failed calling webhook "validation.gatekeeper.sh": Post "https://gatekeeper-webhook-service.gatekeeper-system.svc:443/v1/admit?timeout=3s": context deadline exceeded`)),
+			expected: false,
+		},
+		{
+			name:     "server timeout",
+			err:      k8serrors.NewServerTimeout(schema.GroupResource{Resource: "deployments"}, "create", 1),
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, isWebhookDenial(test.err))
+		})
+	}
+}
+
+func TestRegistryRegex(t *testing.T) {
+	tests := []struct {
+		registry string
+		valid    bool
+	}{
+		{registry: "gcr.io", valid: true},
+		{registry: "registry.example.com:5000/pixie", valid: true},
+		{registry: "localhost:5000", valid: true},
+		{registry: "reg.io/my-org/pixie", valid: true},
+		{registry: "reg.io/my__org", valid: true},
+		{registry: "reg.io/a--b", valid: true},
+		{registry: "reg.io/a.b_c", valid: true},
+		{registry: "reg.io/my___org", valid: false},
+		{registry: "reg.io/a._b", valid: false},
+		{registry: "reg.io/-org", valid: false},
+		{registry: "reg.io/org-", valid: false},
+		{registry: "reg.io/MyOrg", valid: false},
+		{registry: "reg.io//pixie", valid: false},
+		{registry: "reg.io:port/pixie", valid: false},
+		{registry: "-reg.io", valid: false},
+		{registry: "https://reg.io", valid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.registry, func(t *testing.T) {
+			assert.Equal(t, test.valid, registryRegex.MatchString(test.registry))
+		})
+	}
+}
+
+func writeTestFile(t *testing.T, name, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	return path
+}
+
+func TestReadVersionString(t *testing.T) {
+	versionFile := writeTestFile(t, "version", "0.12.3\n")
+	emptyFile := writeTestFile(t, "empty", " \n")
+
+	tests := []struct {
+		name      string
+		version   string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "literal version",
+			version:  "0.12.3",
+			expected: "0.12.3",
+		},
+		{
+			name:     "version file",
+			version:  "@" + versionFile,
+			expected: "0.12.3",
+		},
+		{
+			name:      "empty version file",
+			version:   "@" + emptyFile,
+			expectErr: true,
+		},
+		{
+			name:      "missing version file",
+			version:   "@" + filepath.Join(t.TempDir(), "missing"),
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v, err := readVersionString(test.version)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, v)
+		})
+	}
+}
+
+func TestValidateNamespace(t *testing.T) {
+	tests := []struct {
+		namespace string
+		valid     bool
+	}{
+		{namespace: "pl", valid: true},
+		{namespace: "pixie-system", valid: true},
+		{namespace: "", valid: false},
+		{namespace: "Pixie", valid: false},
+		{namespace: "pl_system", valid: false},
+		{namespace: "-pl", valid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.namespace, func(t *testing.T) {
+			err := validateNamespace(test.namespace)
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestMissingYAMLs(t *testing.T) {
+	yamlMap := map[string]string{
+		"px_olm":       "kind: Namespace",
+		"catalog":      "kind: CatalogSource",
+		"subscription": " ",
+		"vizier":       "kind: Vizier",
+	}
+
+	assert.Equal(t, []string{"subscription", "vizier_crd"}, missingYAMLs(yamlMap, false))
+	assert.Equal(t, []string{"olm_crd", "olm", "subscription", "vizier_crd"}, missingYAMLs(yamlMap, true))
+
+	yamlMap["subscription"] = "kind: Subscription"
+	yamlMap["vizier_crd"] = "kind: CustomResourceDefinition"
+	assert.Empty(t, missingYAMLs(yamlMap, false))
+}
+
+func TestReadMetadataFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		contents  string
+		expected  *deployMetadata
+		expectErr bool
+	}{
+		{
+			name:     "yaml",
+			contents: "labels:\n  team: observability\nannotations:\n  owner: sre\n",
+			expected: &deployMetadata{
+				Labels:      map[string]string{"team": "observability"},
+				Annotations: map[string]string{"owner": "sre"},
+			},
+		},
+		{
+			name:     "json",
+			contents: `{"labels": {"team": "observability"}, "annotations": {"owner": "sre"}}`,
+			expected: &deployMetadata{
+				Labels:      map[string]string{"team": "observability"},
+				Annotations: map[string]string{"owner": "sre"},
+			},
+		},
+		{
+			name:      "unknown yaml field",
+			contents:  "label:\n  team: observability\n",
+			expectErr: true,
+		},
+		{
+			name:      "unknown json field",
+			contents:  `{"labels": {"team": "observability"}, "annotation": {"owner": "sre"}}`,
+			expectErr: true,
+		},
+		{
+			name:      "malformed",
+			contents:  `{"labels": `,
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			md, err := readMetadataFile(writeTestFile(t, "metadata", test.contents))
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, md)
+		})
+	}
+}
+
+func TestMergeMetadata(t *testing.T) {
+	merged := mergeMetadata(
+		map[string]string{"team": "observability", "env": "staging"},
+		map[string]string{"env": "prod", "region": "us-west1"},
+	)
+	assert.Equal(t, map[string]string{"team": "observability", "env": "prod", "region": "us-west1"}, merged)
+
+	assert.Equal(t, map[string]string{}, mergeMetadata(nil, nil))
+}
+
+func TestValidateMetadata(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		expectErr   bool
+	}{
+		{
+			name:        "valid",
+			labels:      map[string]string{"px.dev/team": "observability"},
+			annotations: map[string]string{"px.dev/owner": "Free-form text, with spaces."},
+		},
+		{
+			name:      "invalid label key",
+			labels:    map[string]string{"team name": "observability"},
+			expectErr: true,
+		},
+		{
+			name:      "invalid label value",
+			labels:    map[string]string{"team": "observability team"},
+			expectErr: true,
+		},
+		{
+			name:        "invalid annotation key",
+			annotations: map[string]string{"-owner": "sre"},
+			expectErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateMetadata(test.labels, test.annotations)
+			if test.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestIsDowngrade(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed string
+		requested string
+		expected  bool
+	}{
+		{
+			name:      "downgrade",
+			installed: "0.12.3",
+			requested: "0.11.9",
+			expected:  true,
+		},
+		{
+			name:      "upgrade",
+			installed: "0.11.9",
+			requested: "0.12.3",
+			expected:  false,
+		},
+		{
+			name:      "same version",
+			installed: "0.12.3",
+			requested: "0.12.3",
+			expected:  false,
+		},
+		{
+			name:      "pre-release of the installed version",
+			installed: "0.12.3",
+			requested: "0.12.3-rc.1",
+			expected:  true,
+		},
+		{
+			name:      "release of the installed pre-release",
+			installed: "0.12.3-rc.1",
+			requested: "0.12.3",
+			expected:  false,
+		},
+		{
+			name:      "older pre-release",
+			installed: "0.12.3-rc.2",
+			requested: "0.12.3-rc.1",
+			expected:  true,
+		},
+		{
+			name:      "unparseable installed version",
+			installed: "",
+			requested: "0.12.3",
+			expected:  false,
+		},
+		{
+			name:      "unparseable requested version",
+			installed: "0.12.3",
+			requested: "latest",
+			expected:  false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, isDowngrade(test.installed, test.requested))
+		})
+	}
+}
+
+func waitingStatus(image, reason, message string) v1.ContainerStatus {
+	return v1.ContainerStatus{
+		Image: image,
+		State: v1.ContainerState{
+			Waiting: &v1.ContainerStateWaiting{Reason: reason, Message: message},
+		},
+	}
+}
+
+func TestPodImagePullError(t *testing.T) {
+	running := v1.ContainerStatus{
+		Image: "vizier-pem_image:0.12.3",
+		State: v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+	}
+
+	tests := []struct {
+		name     string
+		status   v1.PodStatus
+		expected string
+	}{
+		{
+			name: "container image pull error",
+			status: v1.PodStatus{
+				ContainerStatuses: []v1.ContainerStatus{waitingStatus("vizier-pem_image:0.12.3", "ErrImagePull", "not found")},
+			},
+			expected: "vizier-pem_image:0.12.3: ErrImagePull not found",
+		},
+		{
+			name: "init container image pull backoff",
+			status: v1.PodStatus{
+				InitContainerStatuses: []v1.ContainerStatus{waitingStatus("busybox:1.36", "ImagePullBackOff", "Back-off pulling image")},
+				ContainerStatuses:     []v1.ContainerStatus{waitingStatus("vizier-pem_image:0.12.3", "PodInitializing", "")},
+			},
+			expected: "busybox:1.36: ImagePullBackOff Back-off pulling image",
+		},
+		{
+			name: "init container reported first",
+			status: v1.PodStatus{
+				InitContainerStatuses: []v1.ContainerStatus{waitingStatus("busybox:1.36", "ErrImagePull", "denied")},
+				ContainerStatuses:     []v1.ContainerStatus{waitingStatus("vizier-pem_image:0.12.3", "ImagePullBackOff", "")},
+			},
+			expected: "busybox:1.36: ErrImagePull denied",
+		},
+		{
+			name: "other waiting reason",
+			status: v1.PodStatus{
+				ContainerStatuses: []v1.ContainerStatus{waitingStatus("vizier-pem_image:0.12.3", "CrashLoopBackOff", "back-off restarting")},
+			},
+			expected: "",
+		},
+		{
+			name: "not waiting",
+			status: v1.PodStatus{
+				InitContainerStatuses: []v1.ContainerStatus{running},
+				ContainerStatuses:     []v1.ContainerStatus{running},
+			},
+			expected: "",
+		},
+		{
+			name:     "no container statuses",
+			status:   v1.PodStatus{},
+			expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, podImagePullError(&test.status))
+		})
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe to write to from the event watch goroutine.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func testWarningEvent(name, resourceVersion, reason, message string) *v1.Event {
+	return &v1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "pl", ResourceVersion: resourceVersion},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: "vizier-pem-abc"},
+		Type:           v1.EventTypeWarning,
+		Reason:         reason,
+		Message:        message,
+	}
+}
+
+func TestWatchWarningEvents(t *testing.T) {
+	out := &syncBuffer{}
+	utils.SetOutput(out)
+	defer utils.SetOutput(os.Stderr)
+
+	clientset := fake.NewSimpleClientset(testWarningEvent("old", "1", "BackOff", "restarted before the deploy"))
+	watchers := []*watch.FakeWatcher{watch.NewFake(), watch.NewFake()}
+	var mu sync.Mutex
+	var resourceVersions []string
+	clientset.PrependWatchReactor("events", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		resourceVersions = append(resourceVersions, action.(k8stesting.WatchAction).GetWatchRestrictions().ResourceVersion)
+		if len(resourceVersions) > len(watchers) {
+			return true, nil, errors.New("unexpected watch")
+		}
+		return true, watchers[len(resourceVersions)-1], nil
+	})
+	watchCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(resourceVersions)
+	}
+
+	stop := watchWarningEvents(clientset, "pl")
+
+	watchers[0].Add(testWarningEvent("scheduling", "2", "FailedScheduling", "0/3 nodes are available"))
+	watchers[0].Modify(testWarningEvent("pull", "3", "Failed", "ErrImagePull"))
+	assert.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "[Failed] pod/vizier-pem-abc: ErrImagePull")
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, out.String(), "[FailedScheduling] pod/vizier-pem-abc: 0/3 nodes are available")
+	assert.NotContains(t, out.String(), "restarted before the deploy")
+
+	// The server closing the watch resumes it from the last seen event.
+	watchers[0].Stop()
+	require.Eventually(t, func() bool { return watchCount() == 2 }, 5*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, "3", resourceVersions[1])
+	mu.Unlock()
+
+	stop()
+	assert.Eventually(t, watchers[1].IsStopped, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, watchCount())
+}
@@ -32,6 +32,9 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 
 	"px.dev/pixie/src/pixie_cli/pkg/components"
 	cliUtils "px.dev/pixie/src/pixie_cli/pkg/utils"
@@ -53,7 +56,10 @@ func init() {
 
 	GetCmd.AddCommand(GetPEMsCmd)
 	GetCmd.AddCommand(GetViziersCmd)
+	GetInstalledVersionCmd.Flags().StringP("namespace", "n", "", "The namespace Vizier is deployed in")
+
 	GetCmd.AddCommand(GetClusterCmd)
+	GetCmd.AddCommand(GetInstalledVersionCmd)
 }
 
 // GetPEMsCmd is the "get pem" command.
@@ -187,6 +193,121 @@ var GetClusterCmd = &cobra.Command{
 	},
 }
 
+// GetInstalledVersionCmd is the "get installed-version" command, which reports the Vizier version running
+// in the current kubeconfig cluster based on the image tags of the Vizier workloads.
+var GetInstalledVersionCmd = &cobra.Command{
+	Use:   "installed-version",
+	Short: "Get the version of Vizier running in the current kubeconfig cluster",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("namespace", cmd.Flags().Lookup("namespace"))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("output")
+		format = strings.ToLower(format)
+		ns, _ := cmd.Flags().GetString("namespace")
+		if ns == "" {
+			ns = vizier.MustFindVizierNamespace()
+		} else if err := validateNamespace(ns); err != nil {
+			cliUtils.WithError(err).Fatal("Invalid --namespace")
+		}
+
+		clientset := k8s.GetClientset(k8s.GetConfig())
+		images, err := getVizierImages(clientset, ns)
+		if err != nil {
+			cliUtils.WithError(err).Fatal("Failed to get Vizier workloads")
+		}
+		if len(images) == 0 {
+			cliUtils.Fatalf("No Vizier workloads found in namespace %s", ns)
+		}
+
+		names := make([]string, 0, len(images))
+		for name := range images {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		w := components.CreateStreamWriter(format, os.Stdout)
+		versions := make(map[string]bool)
+		w.SetHeader("installed_version", []string{"Component", "Image", "Version"})
+		for _, name := range names {
+			version, tagged := imageVersion(images[name])
+			// Digest-pinned images carry no version, so they can't be compared against the tagged ones.
+			if tagged {
+				versions[version] = true
+			}
+			_ = w.Write([]interface{}{name, images[name], version})
+		}
+		w.Finish()
+
+		if len(versions) > 1 {
+			cliUtils.Error("Vizier components are running mismatched versions")
+			return
+		}
+		if len(versions) == 0 {
+			cliUtils.Info("Installed Vizier version: unknown (images are pinned by digest)")
+			return
+		}
+		for v := range versions {
+			cliUtils.Infof("Installed Vizier version: %s", v)
+		}
+	},
+}
+
+// getVizierImages returns the Vizier image used by each workload in the given namespace, keyed by
+// "<kind>/<name>/<container>".
+func getVizierImages(clientset kubernetes.Interface, ns string) (map[string]string, error) {
+	images := make(map[string]string)
+	addImages := func(kind, name string, containers []v1.Container) {
+		for _, c := range containers {
+			if !strings.Contains(c.Image, "vizier-") {
+				continue
+			}
+			images[fmt.Sprintf("%s/%s/%s", kind, name, c.Name)] = c.Image
+		}
+	}
+
+	ctx := context.Background()
+	deps, err := clientset.AppsV1().Deployments(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deps.Items {
+		addImages("deployment", d.Name, d.Spec.Template.Spec.Containers)
+	}
+	sets, err := clientset.AppsV1().StatefulSets(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sets.Items {
+		addImages("statefulset", s.Name, s.Spec.Template.Spec.Containers)
+	}
+	daemons, err := clientset.AppsV1().DaemonSets(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range daemons.Items {
+		addImages("daemonset", d.Name, d.Spec.Template.Spec.Containers)
+	}
+	return images, nil
+}
+
+// imageVersion returns the version of the given image reference and whether it came from a tag.
+// Images pinned only by digest report the digest instead, since no tag is available.
+func imageVersion(image string) (string, bool) {
+	digest := ""
+	if idx := strings.Index(image, "@"); idx != -1 {
+		image, digest = image[:idx], image[idx+1:]
+	}
+	idx := strings.LastIndex(image, ":")
+	if idx != -1 && !strings.Contains(image[idx:], "/") {
+		return image[idx+1:], true
+	}
+	if digest != "" {
+		return digest, false
+	}
+	return "latest", true
+}
+
 // GetCmd is the "get" command.
 var GetCmd = &cobra.Command{
 	Use:   "get",
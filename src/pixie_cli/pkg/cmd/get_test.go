@@ -0,0 +1,127 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestImageVersion(t *testing.T) {
+	tests := []struct {
+		name            string
+		image           string
+		expectedVersion string
+		expectedTagged  bool
+	}{
+		{
+			name:            "tagged",
+			image:           "gcr.io/pixie-oss/pixie-prod/vizier-kelvin_image:0.12.3",
+			expectedVersion: "0.12.3",
+			expectedTagged:  true,
+		},
+		{
+			name:            "tagged with registry port",
+			image:           "registry.local:5000/vizier-pem_image:0.12.3",
+			expectedVersion: "0.12.3",
+			expectedTagged:  true,
+		},
+		{
+			name:            "tagged and digest-pinned",
+			image:           "gcr.io/pixie-oss/vizier-pem_image:0.12.3@sha256:abc123",
+			expectedVersion: "0.12.3",
+			expectedTagged:  true,
+		},
+		{
+			name:            "digest-pinned",
+			image:           "gcr.io/pixie-oss/vizier-pem_image@sha256:abc123",
+			expectedVersion: "sha256:abc123",
+			expectedTagged:  false,
+		},
+		{
+			name:            "digest-pinned with registry port",
+			image:           "registry.local:5000/vizier-pem_image@sha256:abc123",
+			expectedVersion: "sha256:abc123",
+			expectedTagged:  false,
+		},
+		{
+			name:            "untagged",
+			image:           "registry.local:5000/vizier-pem_image",
+			expectedVersion: "latest",
+			expectedTagged:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			version, tagged := imageVersion(test.image)
+			assert.Equal(t, test.expectedVersion, version)
+			assert.Equal(t, test.expectedTagged, tagged)
+		})
+	}
+}
+
+func testPodSpec(images ...string) v1.PodTemplateSpec {
+	spec := v1.PodTemplateSpec{}
+	for i, image := range images {
+		spec.Spec.Containers = append(spec.Spec.Containers, v1.Container{
+			Name:  fmt.Sprintf("container-%d", i),
+			Image: image,
+		})
+	}
+	return spec
+}
+
+func TestGetVizierImages(t *testing.T) {
+	objs := []runtime.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "kelvin", Namespace: "pl"},
+			Spec:       appsv1.DeploymentSpec{Template: testPodSpec("vizier-kelvin_image:0.12.3", "envoy:1.2")},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "vizier-metadata", Namespace: "pl"},
+			Spec:       appsv1.StatefulSetSpec{Template: testPodSpec("vizier-metadata_server_image:0.12.3")},
+		},
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "vizier-pem", Namespace: "pl"},
+			Spec:       appsv1.DaemonSetSpec{Template: testPodSpec("vizier-pem_image@sha256:abc123")},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "kelvin", Namespace: "other"},
+			Spec:       appsv1.DeploymentSpec{Template: testPodSpec("vizier-kelvin_image:0.11.0")},
+		},
+	}
+	clientset := fake.NewSimpleClientset(objs...)
+
+	images, err := getVizierImages(clientset, "pl")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"deployment/kelvin/container-0":           "vizier-kelvin_image:0.12.3",
+		"statefulset/vizier-metadata/container-0": "vizier-metadata_server_image:0.12.3",
+		"daemonset/vizier-pem/container-0":        "vizier-pem_image@sha256:abc123",
+	}, images)
+}
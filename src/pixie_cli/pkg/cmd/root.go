@@ -19,12 +19,14 @@
 package cmd
 
 import (
+	"io"
 	"os"
 	"regexp"
 	"strings"
 
 	"github.com/fatih/color"
 	"github.com/segmentio/analytics-go/v3"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
@@ -59,6 +61,12 @@ func init() {
 	RootCmd.PersistentFlags().String("direct_vizier_key", "", "Should be set if direct_vizier_addr is set, the key to authenticate whether the user has permissions to connect to the Vizier service.")
 	viper.BindPFlag("direct_vizier_key", RootCmd.PersistentFlags().Lookup("direct_vizier_key"))
 
+	RootCmd.PersistentFlags().String("log_file", "", "If set, CLI logs are appended to this file")
+	viper.BindPFlag("log_file", RootCmd.PersistentFlags().Lookup("log_file"))
+
+	RootCmd.PersistentFlags().Bool("log_console", true, "Whether to also write CLI logs to the console when log_file is set")
+	viper.BindPFlag("log_console", RootCmd.PersistentFlags().Lookup("log_console"))
+
 	RootCmd.AddCommand(VersionCmd)
 	RootCmd.AddCommand(AuthCmd)
 	RootCmd.AddCommand(CollectLogsCmd)
@@ -112,12 +120,47 @@ func printEnvVars() {
 		return
 	}
 	green := color.New(color.Bold, color.FgGreen)
-	green.Fprintf(os.Stderr, "*******************************\n")
-	green.Fprintf(os.Stderr, "* ENV VARS\n")
+	green.Fprintf(utils.Output(), "*******************************\n")
+	green.Fprintf(utils.Output(), "* ENV VARS\n")
 	for _, env := range pxEnvs {
-		green.Fprintf(os.Stderr, "* \t %s\n", env)
+		green.Fprintf(utils.Output(), "* \t %s\n", env)
+	}
+	green.Fprintf(utils.Output(), "*******************************\n")
+}
+
+// ansiEscapeRegex matches the SGR escape sequences used to color CLI output.
+var ansiEscapeRegex = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// noColorWriter strips color escape sequences before writing to the underlying writer.
+type noColorWriter struct {
+	w io.Writer
+}
+
+func (n *noColorWriter) Write(p []byte) (int, error) {
+	if _, err := n.w.Write(ansiEscapeRegex.ReplaceAll(p, nil)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// setupLogFile redirects the CLI and logrus output to the file specified by log_file, if any.
+func setupLogFile() {
+	logFile := viper.GetString("log_file")
+	if logFile == "" {
+		return
+	}
+	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		utils.WithError(err).Fatalf("Failed to open log file %s", logFile)
+	}
+
+	// Colors are only meaningful on a terminal, so keep the escape codes out of the file.
+	var w io.Writer = &noColorWriter{w: f}
+	if viper.GetBool("log_console") {
+		w = io.MultiWriter(os.Stderr, w)
 	}
-	green.Fprintf(os.Stderr, "*******************************\n")
+	log.SetOutput(w)
+	utils.SetOutput(w)
 }
 
 // RootCmd is the base command for Cobra.
@@ -127,6 +170,7 @@ var RootCmd = &cobra.Command{
 	// TODO(zasgar): Add description and update this.
 	Long: `The Pixie command line interface.`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		setupLogFile()
 		printEnvVars()
 
 		cloudAddr := viper.GetString("cloud_addr")
@@ -53,6 +53,7 @@ func init() {
 	VizierUpdateCmd.Flags().MarkHidden("vizier_version")
 	VizierUpdateCmd.Flags().BoolP("redeploy_etcd", "e", false, "Whether or not to redeploy etcd during the update")
 	VizierUpdateCmd.Flags().StringP("cluster", "c", "", "Run only on selected cluster")
+	VizierUpdateCmd.Flags().Bool("allow_downgrade", false, "Allow updating to a version older than the one currently running")
 }
 
 // UpdateCmd is the "update" sub-command of the CLI.
@@ -73,11 +74,16 @@ var VizierUpdateCmd = &cobra.Command{
 	PreRun: func(cmd *cobra.Command, args []string) {
 		viper.BindPFlag("vizier_version", cmd.Flags().Lookup("vizier_version"))
 		viper.BindPFlag("redeploy_etcd", cmd.Flags().Lookup("redeploy_etcd"))
+		viper.BindPFlag("allow_downgrade", cmd.Flags().Lookup("allow_downgrade"))
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		versionString := viper.GetString("vizier_version")
+		versionString, err := readVersionString(viper.GetString("vizier_version"))
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to read --vizier_version")
+		}
 		cloudAddr := viper.GetString("cloud_addr")
 		redeployEtcd := viper.GetBool("redeploy_etcd")
+		allowDowngrade := viper.GetBool("allow_downgrade")
 
 		clusterID := uuid.Nil
 		clusterStr, _ := cmd.Flags().GetString("cluster")
@@ -125,12 +131,9 @@ var VizierUpdateCmd = &cobra.Command{
 			}
 		}
 
-		if sv, err := semver.Parse(clusterInfo.VizierVersion); err == nil {
-			svNew := semver.MustParse(versionString)
-			if svNew.Compare(sv) < 0 {
-				utils.Fatalf("Cannot upgrade current version %s to requested older version %s",
-					sv.String(), svNew.String())
-			}
+		if !allowDowngrade && isDowngrade(clusterInfo.VizierVersion, versionString) {
+			utils.Fatalf("Cannot upgrade current version %s to requested older version %s. Pass --allow_downgrade to update anyway",
+				clusterInfo.VizierVersion, versionString)
 		}
 
 		_ = pxanalytics.Client().Enqueue(&analytics.Track{
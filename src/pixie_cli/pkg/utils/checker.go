@@ -21,6 +21,7 @@ package utils
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/blang/semver"
@@ -54,28 +55,138 @@ func NamedCheck(name string, check func() error) Checker {
 	return &namedCheck{name: name, check: check}
 }
 
-// VersionCompatible checks to make sure version >= minVersion as per semver.
-func VersionCompatible(version string, minVersion string) (bool, error) {
-	// We don't actually care about pre-release tags, so drop them since they sometimes cause parse error.
-	sp := strings.Split(version, "-")
-	if len(sp) == 0 {
-		return false, errors.New("Failed to parse version string")
+var (
+	// ErrInvalidVersion is the error kind returned when the version being checked can't be parsed.
+	ErrInvalidVersion = errors.New("invalid version")
+	// ErrInvalidMinVersion is the error kind returned when the minimum version can't be parsed.
+	ErrInvalidMinVersion = errors.New("invalid minimum version")
+	// ErrInvalidMaxVersion is the error kind returned when the maximum version can't be parsed.
+	ErrInvalidMaxVersion = errors.New("invalid maximum version")
+)
+
+// VersionParseError is returned when a version string can't be parsed as semver.
+// Use errors.Is with ErrInvalidVersion, ErrInvalidMinVersion or ErrInvalidMaxVersion to determine which
+// version was malformed.
+type VersionParseError struct {
+	// Kind is one of ErrInvalidVersion, ErrInvalidMinVersion or ErrInvalidMaxVersion.
+	Kind error
+	// Version is the version string that failed to parse.
+	Version string
+	// Err is the underlying parse error.
+	Err error
+}
+
+func (e *VersionParseError) Error() string {
+	return fmt.Sprintf("%s '%s': %s", e.Kind, e.Version, e.Err)
+}
+
+// Is returns whether the target is the kind of this error.
+func (e *VersionParseError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the underlying parse error.
+func (e *VersionParseError) Unwrap() error {
+	return e.Err
+}
+
+// parseVersion parses the given version as semver, ignoring any "v" prefix.
+// Parse failures are returned as a VersionParseError of the given kind.
+func parseVersion(version string, kind error) (semver.Version, error) {
+	v, err := makeVersion(version)
+	if err != nil {
+		return semver.Version{}, &VersionParseError{Kind: kind, Version: version, Err: err}
 	}
-	version = sp[0]
+	return v, nil
+}
+
+// parseReleaseVersion is like parseVersion, but drops any pre-release tag. This is needed for versions
+// that aren't valid semver past the release, such as kernel versions like 4.14.165-133.209.amzn2.x86_64.
+func parseReleaseVersion(version string, kind error) (semver.Version, error) {
+	v, err := makeVersion(strings.Split(version, "-")[0])
+	if err != nil {
+		return semver.Version{}, &VersionParseError{Kind: kind, Version: version, Err: err}
+	}
+	return v, nil
+}
+
+func makeVersion(version string) (semver.Version, error) {
 	version = strings.TrimPrefix(version, "v")
 	// Minor version can sometime contain a "+", we remove it so it parses properly with semver.
 	version = strings.TrimSuffix(version, "+")
-	minVersion = strings.TrimPrefix(minVersion, "v")
-	v, err := semver.Make(version)
+	return semver.Make(version)
+}
+
+// CompareVersions compares two versions as per semver, including pre-release ordering. It returns -1 if a < b,
+// 0 if a == b and 1 if a > b. A VersionParseError is returned if either version is malformed.
+func CompareVersions(a string, b string) (int, error) {
+	va, err := parseVersion(a, ErrInvalidVersion)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b, ErrInvalidVersion)
+	if err != nil {
+		return 0, err
+	}
+	return va.Compare(vb), nil
+}
+
+// ParseMaxVersion parses a maximum version of the form major, major.minor or major.minor.patch, and
+// returns its components.
+func ParseMaxVersion(maxVersion string) ([]uint64, error) {
+	parts := strings.Split(strings.TrimPrefix(maxVersion, "v"), ".")
+	if len(parts) > 3 {
+		return nil, &VersionParseError{Kind: ErrInvalidMaxVersion, Version: maxVersion, Err: errors.New("expected at most major.minor.patch")}
+	}
+	components := make([]uint64, len(parts))
+	for i, p := range parts {
+		c, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, &VersionParseError{Kind: ErrInvalidMaxVersion, Version: maxVersion, Err: err}
+		}
+		components[i] = c
+	}
+	return components, nil
+}
+
+// VersionAtMost checks that version <= maxVersion, comparing only the components given in maxVersion.
+// For example, "1.25.3" is at most "1.25", but not at most "1.25.2".
+func VersionAtMost(version string, maxVersion string) (bool, error) {
+	v, err := parseReleaseVersion(version, ErrInvalidVersion)
 	if err != nil {
 		return false, err
 	}
-	vMin, err := semver.Make(minVersion)
+	maxComponents, err := ParseMaxVersion(maxVersion)
 	if err != nil {
 		return false, err
 	}
+	// Zero the components that maxVersion leaves out on both sides, so that they don't take part in the comparison.
+	truncated := make([]uint64, 3)
+	bound := make([]uint64, 3)
+	copy(truncated, []uint64{v.Major, v.Minor, v.Patch}[:len(maxComponents)])
+	copy(bound, maxComponents)
+	c, err := CompareVersions(formatVersion(truncated), formatVersion(bound))
+	if err != nil {
+		return false, err
+	}
+	return c <= 0, nil
+}
 
-	return v.GE(vMin), nil
+func formatVersion(components []uint64) string {
+	return fmt.Sprintf("%d.%d.%d", components[0], components[1], components[2])
+}
+
+// VersionCompatible checks to make sure version >= minVersion as per semver, ignoring pre-release tags.
+func VersionCompatible(version string, minVersion string) (bool, error) {
+	v, err := parseReleaseVersion(version, ErrInvalidVersion)
+	if err != nil {
+		return false, err
+	}
+	minV, err := parseReleaseVersion(minVersion, ErrInvalidMinVersion)
+	if err != nil {
+		return false, err
+	}
+	return v.Compare(minV) >= 0, nil
 }
 
 type jobAdapter struct {
@@ -101,10 +212,44 @@ func RunClusterChecks(checks []Checker) error {
 	return jr.RunAndMonitor()
 }
 
+// ClusterCheckOptions configures how the default cluster checks are run.
+type ClusterCheckOptions struct {
+	// SkipKernelCheck skips the per-node kernel version check, for platforms where the reported kernel version is unreliable.
+	SkipKernelCheck bool
+	// MaxK8sVersion, if set, fails the checks when the K8s server version is newer than this version.
+	MaxK8sVersion string
+	// SkipCreateNamespaceCheck skips checking that the user can create namespaces, for when the namespace already exists.
+	SkipCreateNamespaceCheck bool
+	// FailOnWarn fails the checks that would otherwise only warn and pass, such as a kernel check that can't list nodes.
+	FailOnWarn bool
+}
+
 // RunDefaultClusterChecks runs the default configured checks.
-func RunDefaultClusterChecks() error {
+func RunDefaultClusterChecks(opts *ClusterCheckOptions) error {
 	fmt.Printf("\nRunning Cluster Checks:\n")
-	return RunClusterChecks(DefaultClusterChecks)
+	checks := DefaultClusterChecks
+	if opts != nil && (opts.SkipKernelCheck || opts.SkipCreateNamespaceCheck || opts.FailOnWarn) {
+		if opts.SkipKernelCheck {
+			fmt.Printf("Skipping kernel version check\n")
+		}
+		checks = make([]Checker, 0, len(DefaultClusterChecks))
+		for _, c := range DefaultClusterChecks {
+			if opts.SkipKernelCheck && c == kernelVersionCheck {
+				continue
+			}
+			if opts.SkipCreateNamespaceCheck && c == userCanCreateNamespace {
+				continue
+			}
+			if opts.FailOnWarn && c == kernelVersionCheck {
+				c = nodeKernelVersionCheck(true)
+			}
+			checks = append(checks, c)
+		}
+	}
+	if opts != nil && opts.MaxK8sVersion != "" {
+		checks = append(checks[:len(checks):len(checks)], k8sMaxVersionCheck(opts.MaxK8sVersion))
+	}
+	return RunClusterChecks(checks)
 }
 
 // RunExtraClusterChecks runs the extra configured checks.
@@ -19,6 +19,7 @@
 package utils_test
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
@@ -33,7 +34,7 @@ func TestVersionCompatible(t *testing.T) {
 		minVersion  string
 		testVersion string
 		ok          bool
-		expectErr   bool
+		expectErr   error
 	}{
 		{
 			minVersion:  "4.14.0",
@@ -53,7 +54,12 @@ func TestVersionCompatible(t *testing.T) {
 		{
 			minVersion:  "4.15.0",
 			testVersion: "a4",
-			expectErr:   true,
+			expectErr:   utils.ErrInvalidVersion,
+		},
+		{
+			minVersion:  "4.15",
+			testVersion: "4.15.0",
+			expectErr:   utils.ErrInvalidMinVersion,
 		},
 	}
 
@@ -61,8 +67,167 @@ func TestVersionCompatible(t *testing.T) {
 		name := fmt.Sprintf("Check %s < %s", test.minVersion, test.testVersion)
 		t.Run(name, func(t *testing.T) {
 			ok, err := utils.VersionCompatible(test.testVersion, test.minVersion)
+			if test.expectErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, test.expectErr))
+				var parseErr *utils.VersionParseError
+				assert.True(t, errors.As(err, &parseErr))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.ok, ok)
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		name      string
+		a         string
+		b         string
+		expected  int
+		expectErr bool
+	}{
+		{
+			name:     "equal",
+			a:        "1.20.3",
+			b:        "v1.20.3",
+			expected: 0,
+		},
+		{
+			name:     "less",
+			a:        "1.19.0",
+			b:        "1.20.0",
+			expected: -1,
+		},
+		{
+			name:     "greater",
+			a:        "4.14.165",
+			b:        "4.14.0",
+			expected: 1,
+		},
+		{
+			name:     "pre-release before release",
+			a:        "0.9.0-rc.1",
+			b:        "0.9.0",
+			expected: -1,
+		},
+		{
+			name:     "pre-release ordering",
+			a:        "v0.9.0-rc.10",
+			b:        "0.9.0-rc.2",
+			expected: 1,
+		},
+		{
+			name:     "build metadata ignored",
+			a:        "1.25.3+k3s1",
+			b:        "1.25.3",
+			expected: 0,
+		},
+		{
+			name:      "non-semver pre-release",
+			a:         "4.14.165-133.209.amzn2.x86_64",
+			b:         "4.14.0",
+			expectErr: true,
+		},
+		{
+			name:      "malformed a",
+			a:         "a4",
+			b:         "4.14.0",
+			expectErr: true,
+		},
+		{
+			name:      "malformed b",
+			a:         "4.14.0",
+			b:         "4.14",
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := utils.CompareVersions(test.a, test.b)
 			if test.expectErr {
-				assert.NotNil(t, err)
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, utils.ErrInvalidVersion))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, c)
+		})
+	}
+}
+
+func TestVersionAtMost(t *testing.T) {
+	tests := []struct {
+		name       string
+		version    string
+		maxVersion string
+		ok         bool
+		expectErr  error
+	}{
+		{
+			name:       "patch release within major.minor",
+			version:    "v1.25.3-gke.100",
+			maxVersion: "1.25",
+			ok:         true,
+		},
+		{
+			name:       "newer minor than major.minor",
+			version:    "v1.26.0",
+			maxVersion: "1.25",
+			ok:         false,
+		},
+		{
+			name:       "older minor than major.minor",
+			version:    "v1.24.9-eks-af3caf",
+			maxVersion: "v1.25",
+			ok:         true,
+		},
+		{
+			name:       "newer patch than major.minor.patch",
+			version:    "1.25.3",
+			maxVersion: "1.25.2",
+			ok:         false,
+		},
+		{
+			name:       "equal major.minor.patch",
+			version:    "1.25.3+k3s1",
+			maxVersion: "1.25.3",
+			ok:         true,
+		},
+		{
+			name:       "major only",
+			version:    "1.30.1",
+			maxVersion: "1",
+			ok:         true,
+		},
+		{
+			name:       "malformed max version",
+			version:    "1.25.3",
+			maxVersion: "1.x",
+			expectErr:  utils.ErrInvalidMaxVersion,
+		},
+		{
+			name:       "too many max version components",
+			version:    "1.25.3",
+			maxVersion: "1.25.3.4",
+			expectErr:  utils.ErrInvalidMaxVersion,
+		},
+		{
+			name:       "malformed version",
+			version:    "a1",
+			maxVersion: "1.25",
+			expectErr:  utils.ErrInvalidVersion,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := utils.VersionAtMost(test.version, test.maxVersion)
+			if test.expectErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, test.expectErr))
 				return
 			}
 			require.NoError(t, err)
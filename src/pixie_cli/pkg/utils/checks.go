@@ -26,8 +26,11 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/fatih/color"
 	"gopkg.in/yaml.v2"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 
 	"px.dev/pixie/src/utils/shared/k8s"
 )
@@ -156,27 +159,42 @@ func detectClusterType() ClusterType {
 	return ClusterTypeUnknown
 }
 
-var (
-	kernelVersionCheck = NamedCheck(fmt.Sprintf("Kernel version > %s", kernelMinVersion), func() error {
-		kubeConfig := k8s.GetConfig()
-		clientset := k8s.GetClientset(kubeConfig)
+// checkNodeKernelVersions checks that all of the nodes in the cluster have a supported kernel version.
+// The check is skipped with a warning if the user isn't allowed to list nodes, unless failOnWarn is set.
+func checkNodeKernelVersions(clientset kubernetes.Interface, failOnWarn bool) error {
+	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if k8serrors.IsForbidden(err) && !failOnWarn {
+		WithColor(color.New(color.FgYellow)).Info("Node kernel checks skipped, the current user has insufficient RBAC permissions to list nodes.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 
-		nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	for _, node := range nodes.Items {
+		compatible, err := VersionCompatible(node.Status.NodeInfo.KernelVersion, kernelMinVersion)
 		if err != nil {
 			return err
 		}
-
-		for _, node := range nodes.Items {
-			compatible, err := VersionCompatible(node.Status.NodeInfo.KernelVersion, kernelMinVersion)
-			if err != nil {
-				return err
-			}
-			if !compatible {
-				return fmt.Errorf("kernel version for node (%s) not supported. Must have minimum kernel version of (%s)", node.Name, kernelMinVersion)
-			}
+		if !compatible {
+			info := node.Status.NodeInfo
+			return fmt.Errorf("kernel version (%s) for node (%s) running %s (%s) not supported. Must have minimum kernel version of (%s)",
+				info.KernelVersion, node.Name, info.OSImage, info.OperatingSystem, kernelMinVersion)
 		}
-		return nil
+	}
+	return nil
+}
+
+func nodeKernelVersionCheck(failOnWarn bool) Checker {
+	return NamedCheck(fmt.Sprintf("Kernel version > %s", kernelMinVersion), func() error {
+		kubeConfig := k8s.GetConfig()
+		clientset := k8s.GetClientset(kubeConfig)
+		return checkNodeKernelVersions(clientset, failOnWarn)
 	})
+}
+
+var (
+	kernelVersionCheck     = nodeKernelVersionCheck(false)
 	clusterTypeIsSupported = NamedCheck("Cluster type is supported", func() error {
 		clusterType := detectClusterType()
 
@@ -262,6 +280,27 @@ var (
 	})
 )
 
+// k8sMaxVersionCheck checks that the K8s server version is no newer than the given version.
+func k8sMaxVersionCheck(maxVersion string) Checker {
+	return NamedCheck(fmt.Sprintf("K8s version <= %s", maxVersion), func() error {
+		kubeConfig := k8s.GetConfig()
+
+		discoveryClient := k8s.GetDiscoveryClient(kubeConfig)
+		version, err := discoveryClient.ServerVersion()
+		if err != nil {
+			return err
+		}
+		ok, err := VersionAtMost(version.GitVersion, maxVersion)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("k8s version (%s) is newer than the maximum supported k8s version of (%s)", version.GitVersion, maxVersion)
+		}
+		return nil
+	})
+}
+
 // DefaultClusterChecks is a list of cluster that are performed by default.
 var DefaultClusterChecks = []Checker{
 	kernelVersionCheck,
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func testNode(name string, kernelVersion string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{
+				KernelVersion:   kernelVersion,
+				OSImage:         "Ubuntu 18.04.5 LTS",
+				OperatingSystem: "linux",
+			},
+		},
+	}
+}
+
+func TestCheckNodeKernelVersions(t *testing.T) {
+	tests := []struct {
+		name        string
+		nodes       []runtime.Object
+		listErr     error
+		failOnWarn  bool
+		expectErr   bool
+		errContains string
+		expectOut   string
+	}{
+		{
+			name:  "supported kernels",
+			nodes: []runtime.Object{testNode("node-1", "5.4.0-1043-gke"), testNode("node-2", "4.14.165-133.209.amzn2.x86_64")},
+		},
+		{
+			name:        "unsupported kernel",
+			nodes:       []runtime.Object{testNode("node-1", "5.4.0"), testNode("node-2", "4.9.0")},
+			expectErr:   true,
+			errContains: "kernel version (4.9.0) for node (node-2) running Ubuntu 18.04.5 LTS (linux)",
+		},
+		{
+			name:      "forbidden node list",
+			listErr:   k8serrors.NewForbidden(schema.GroupResource{Resource: "nodes"}, "", errors.New("no access")),
+			expectOut: "Node kernel checks skipped, the current user has insufficient RBAC permissions to list nodes.",
+		},
+		{
+			name:        "forbidden node list with fail on warn",
+			listErr:     k8serrors.NewForbidden(schema.GroupResource{Resource: "nodes"}, "", errors.New("no access")),
+			failOnWarn:  true,
+			expectErr:   true,
+			errContains: "no access",
+		},
+		{
+			name:        "failed node list",
+			listErr:     errors.New("connection refused"),
+			expectErr:   true,
+			errContains: "connection refused",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(test.nodes...)
+			if test.listErr != nil {
+				clientset.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, test.listErr
+				})
+			}
+
+			out := &bytes.Buffer{}
+			SetOutput(out)
+			defer SetOutput(os.Stderr)
+
+			err := checkNodeKernelVersions(clientset, test.failOnWarn)
+			assert.Contains(t, out.String(), test.expectOut)
+			if !test.expectErr {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.errContains)
+		})
+	}
+}
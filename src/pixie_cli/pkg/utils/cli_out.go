@@ -37,6 +37,19 @@ var defaultCLIOutput = &CLIOutputEntry{
 	err:       nil,
 }
 
+// cliOutput is where all CLI log entries are written to.
+var cliOutput io.Writer = os.Stderr
+
+// SetOutput sets the writer that CLI log entries are written to. Defaults to stderr.
+func SetOutput(w io.Writer) {
+	cliOutput = w
+}
+
+// Output returns the writer that CLI log entries are written to.
+func Output() io.Writer {
+	return cliOutput
+}
+
 // WithColor returns a struct that can be used to log text to the CLI
 // in a specific color.
 func WithColor(c *color.Color) *CLIOutputEntry {
@@ -116,7 +129,7 @@ func (c *CLIOutputEntry) write(w io.Writer, format string, args ...interface{})
 
 // Infof prints the input string to stdout formatted with the input args.
 func (c *CLIOutputEntry) Infof(format string, args ...interface{}) {
-	c.write(os.Stderr, format, args...)
+	c.write(cliOutput, format, args...)
 }
 
 // Info prints the input string to stdout.
@@ -126,22 +139,22 @@ func (c *CLIOutputEntry) Info(str string) {
 
 // Errorf prints the input string to stderr formatted with the input args.
 func (c *CLIOutputEntry) Errorf(format string, args ...interface{}) {
-	c.write(os.Stderr, format, args...)
+	c.write(cliOutput, format, args...)
 }
 
 // Error prints the input string to stderr.
 func (c *CLIOutputEntry) Error(str string) {
-	c.write(os.Stderr, str)
+	c.write(cliOutput, str)
 }
 
 // Fatalf prints the input string to stderr formatted with the input args.
 func (c *CLIOutputEntry) Fatalf(format string, args ...interface{}) {
-	c.write(os.Stderr, format, args...)
+	c.write(cliOutput, format, args...)
 	os.Exit(1)
 }
 
 // Fatal prints the input string to stderr.
 func (c *CLIOutputEntry) Fatal(str string) {
-	c.write(os.Stderr, str)
+	c.write(cliOutput, str)
 	os.Exit(1)
 }
@@ -31,6 +31,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -258,3 +259,54 @@ func ApplyResources(clientset kubernetes.Interface, config *rest.Config, resourc
 
 	return nil
 }
+
+// CreateOrReplaceResources creates the given resources, replacing any that already exist. Unlike ApplyResources,
+// failures to replace existing resources are returned. Namespaced resources which don't specify a namespace are
+// created in the given namespace.
+func CreateOrReplaceResources(clientset kubernetes.Interface, config *rest.Config, resources []*Resource, namespace string) error {
+	apiGroupResources, err := restmapper.GetAPIGroupResources(clientset.Discovery())
+	if err != nil {
+		return err
+	}
+	rm := restmapper.NewDiscoveryRESTMapper(apiGroupResources)
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	for _, resource := range resources {
+		mapping, err := rm.RESTMapping(resource.GVK.GroupKind(), resource.GVK.Version)
+		if err != nil {
+			return err
+		}
+
+		var res dynamic.ResourceInterface = dynamicClient.Resource(mapping.Resource)
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			if resource.Object.GetNamespace() == "" {
+				resource.Object.SetNamespace(namespace)
+			}
+			res = dynamicClient.Resource(mapping.Resource).Namespace(resource.Object.GetNamespace())
+		} else {
+			resource.Object.SetNamespace("")
+		}
+
+		_, err = res.Create(context.Background(), resource.Object, metav1.CreateOptions{})
+		if err == nil {
+			continue
+		}
+		if !k8serrors.IsAlreadyExists(err) {
+			return err
+		}
+
+		existing, err := res.Get(context.Background(), resource.Object.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		resource.Object.SetResourceVersion(existing.GetResourceVersion())
+		if _, err := res.Update(context.Background(), resource.Object, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
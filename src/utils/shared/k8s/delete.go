@@ -20,6 +20,7 @@ package k8s
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"strings"
 	"sync"
@@ -29,8 +30,10 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/printers"
 	"k8s.io/cli-runtime/pkg/resource"
@@ -50,11 +53,33 @@ type ObjectDeleter struct {
 	Clientset  *kubernetes.Clientset
 	RestConfig *rest.Config
 	Timeout    time.Duration
+	// SkipWait returns as soon as the deletes have been issued, rather than waiting for the objects to be removed.
+	SkipWait bool
 
 	rcg           *restClientGetter
 	dynamicClient dynamic.Interface
 }
 
+// StuckObject is a deleted object which still existed when the wait for its deletion timed out.
+type StuckObject struct {
+	Resource   string
+	Namespace  string
+	Name       string
+	Finalizers []string
+	// Reason explains why the object is stuck, when the object reports it. Currently only set for namespaces.
+	Reason string
+}
+
+// DeleteTimeoutError is returned when deleted objects weren't removed before the ObjectDeleter's timeout.
+type DeleteTimeoutError struct {
+	Timeout time.Duration
+	Objects []StuckObject
+}
+
+func (e *DeleteTimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s waiting for %d object(s) to be deleted", e.Timeout, len(e.Objects))
+}
+
 // DeleteCustomObject is used to delete a custom object (instantiation of CRD).
 func (o *ObjectDeleter) DeleteCustomObject(resourceName, resourceValue string) error {
 	if err := o.initRestClientGetter(); err != nil {
@@ -217,8 +242,8 @@ func (o *ObjectDeleter) runDelete(r *resource.Result) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	if found == 0 {
-		return 0, nil
+	if found == 0 || o.SkipWait {
+		return found, nil
 	}
 
 	effectiveTimeout := o.Timeout
@@ -239,7 +264,52 @@ func (o *ObjectDeleter) runDelete(r *resource.Result) (int, error) {
 			ErrOut: io.Discard,
 		},
 	}
-	return found, waitOptions.RunWait()
+	err = waitOptions.RunWait()
+	if err != nil && strings.Contains(err.Error(), wait.ErrWaitTimeout.Error()) {
+		if stuck := o.stuckObjects(deletedInfos); len(stuck) > 0 {
+			return found, &DeleteTimeoutError{Timeout: effectiveTimeout, Objects: stuck}
+		}
+	}
+	return found, err
+}
+
+// stuckObjects returns the given deleted objects which still exist.
+func (o *ObjectDeleter) stuckObjects(infos []*resource.Info) []StuckObject {
+	stuck := make([]StuckObject, 0)
+	for _, info := range infos {
+		obj, err := o.dynamicClient.Resource(info.Mapping.Resource).Namespace(info.Namespace).Get(context.Background(), info.Name, metav1.GetOptions{})
+		if err != nil {
+			continue
+		}
+		s := StuckObject{
+			Resource:   info.Mapping.Resource.Resource,
+			Namespace:  info.Namespace,
+			Name:       info.Name,
+			Finalizers: obj.GetFinalizers(),
+		}
+		if s.Resource == "namespaces" {
+			s.Reason = namespaceDeletionReason(obj)
+		}
+		stuck = append(stuck, s)
+	}
+	return stuck
+}
+
+// namespaceDeletionReason returns the messages of the namespace's active conditions, which report the content
+// and finalizers that are blocking its deletion.
+func namespaceDeletionReason(obj *unstructured.Unstructured) string {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	msgs := make([]string, 0)
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok || cond["status"] != "True" {
+			continue
+		}
+		if msg, ok := cond["message"].(string); ok && msg != "" {
+			msgs = append(msgs, msg)
+		}
+	}
+	return strings.Join(msgs, "; ")
 }
 
 func (o *ObjectDeleter) deleteResource(info *resource.Info, deleteOptions *metav1.DeleteOptions) (runtime.Object, error) {